@@ -0,0 +1,58 @@
+package unifi_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/northbright/unifi"
+)
+
+// TestConcurrentListCallsBeforeDetection drives many goroutines through
+// ListSta/ListDevices on a freshly-created Unifi whose controller type
+// hasn't been detected yet, so they race on triggering
+// detectControllerType/buildURLs. Run with -race to catch regressions.
+func TestConcurrentListCallsBeforeDetection(t *testing.T) {
+	const site = "default"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[]}`)
+	})
+	mux.HandleFunc("/api/s/"+site+"/stat/sta", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[]}`)
+	})
+	mux.HandleFunc("/api/s/"+site+"/stat/device", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[]}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u, err := unifi.New(srv.URL, "admin", "pw")
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := u.ListSta(context.Background(), site); err != nil {
+				t.Errorf("ListSta() error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := u.ListDevices(context.Background(), site); err != nil {
+				t.Errorf("ListDevices() error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,55 @@
+package unifi_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/northbright/unifi"
+)
+
+// TestDetectControllerTypeUniFiOS checks that a controller which echoes
+// X-CSRF-Token on its root URL is detected as UniFi OS, so subsequent calls
+// are routed through /proxy/network and carry the token back.
+func TestDetectControllerTypeUniFiOS(t *testing.T) {
+	const site = "default"
+	const csrfToken = "test-csrf-token"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(unifi.CSRFTokenHeader, csrfToken)
+	})
+	mux.HandleFunc("/api/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[]}`)
+	})
+	mux.HandleFunc("/proxy/network/api/s/"+site+"/stat/sta", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(unifi.CSRFTokenHeader) != csrfToken {
+			http.Error(w, "missing csrf token", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[{"mac":"aa:bb:cc:dd:ee:ff"}]}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u, err := unifi.New(srv.URL, "admin", "pw")
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	if err = u.Login(context.Background()); err != nil {
+		t.Fatalf("Login() error: %v", err)
+	}
+
+	stas, err := u.ListSta(context.Background(), site)
+	if err != nil {
+		t.Fatalf("ListSta() error: %v", err)
+	}
+
+	if len(stas) != 1 || stas[0].MAC != "aa:bb:cc:dd:ee:ff" {
+		t.Fatalf("ListSta() = %+v, want 1 STA with MAC aa:bb:cc:dd:ee:ff", stas)
+	}
+}
@@ -0,0 +1,267 @@
+package unifi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/northbright/unifi/model"
+)
+
+const defaultMaxBackoff = 30 * time.Second
+
+// subscribeConfig holds the state SubscribeOption funcs mutate.
+type subscribeConfig struct {
+	keyPrefixes []string
+	reconnect   bool
+	maxBackoff  time.Duration
+}
+
+// SubscribeOption configures SubscribeEvents.
+type SubscribeOption func(*subscribeConfig)
+
+// WithEventKeyPrefixes restricts delivery to events whose Key starts with
+// one of prefixes, e.g. "EVT_WU_", "EVT_LU_". Without this option every
+// event is delivered.
+func WithEventKeyPrefixes(prefixes ...string) SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.keyPrefixes = prefixes
+	}
+}
+
+// WithReconnect makes SubscribeEvents transparently redial with exponential
+// backoff, capped at maxBackoff, instead of closing its channels when the
+// connection drops for a reason other than ctx being cancelled. maxBackoff
+// <= 0 means 30s.
+func WithReconnect(maxBackoff time.Duration) SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.reconnect = true
+		c.maxBackoff = maxBackoff
+	}
+}
+
+func (c *subscribeConfig) matches(e model.Event) bool {
+	if len(c.keyPrefixes) == 0 {
+		return true
+	}
+	for _, p := range c.keyPrefixes {
+		if strings.HasPrefix(e.Key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventsURL builds the wss:// (or ws://, if baseURL is plain http) URL for
+// site's event stream.
+func (u *Unifi) eventsURL(site string) (string, error) {
+	if site == "" {
+		site = defaultSite
+	}
+
+	u.mu.RLock()
+	controllerType := u.controllerType
+	u.mu.RUnlock()
+
+	prefix := ""
+	if controllerType == ControllerUniFiOS {
+		prefix = "/proxy/network"
+	}
+
+	refURL, err := url.Parse(fmt.Sprintf("%v/wss/s/%v/events", prefix, site))
+	if err != nil {
+		err = fmt.Errorf("url.Parse() error: %v", err)
+		return "", err
+	}
+
+	wsURL := u.baseURL.ResolveReference(refURL)
+	switch wsURL.Scheme {
+	case "https":
+		wsURL.Scheme = "wss"
+	case "http":
+		wsURL.Scheme = "ws"
+	}
+
+	return wsURL.String(), nil
+}
+
+// dialEvents dials site's events WebSocket, reusing u's cookie jar so the
+// controller recognizes the session established by Login.
+func (u *Unifi) dialEvents(ctx context.Context, site string) (*websocket.Conn, error) {
+	var err error
+
+	wsURL, err := u.eventsURL(site)
+	if err != nil {
+		err = fmt.Errorf("eventsURL() error: %v", err)
+		return nil, err
+	}
+
+	u.mu.RLock()
+	csrfToken := u.csrfToken
+	u.mu.RUnlock()
+
+	header := http.Header{}
+	if csrfToken != "" {
+		header.Set(CSRFTokenHeader, csrfToken)
+	}
+
+	dialer := &websocket.Dialer{
+		Jar:             u.jar,
+		TLSClientConfig: u.tlsConfig,
+	}
+
+	conn, _, err := dialer.DialContext(ctx, wsURL, header)
+	if err != nil {
+		err = fmt.Errorf("DialContext() error: %v", err)
+		return nil, err
+	}
+
+	return conn, err
+}
+
+// readEvents decodes frames from conn and sends matching events on out
+// until conn errors, ctx is cancelled, or the connection is closed.
+func (u *Unifi) readEvents(ctx context.Context, conn *websocket.Conn, cfg *subscribeConfig, out chan<- model.Event) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			err = fmt.Errorf("ReadMessage() error: %v", err)
+			return err
+		}
+
+		frame := struct {
+			Data []model.Event `json:"data"`
+		}{}
+		if err := json.Unmarshal(data, &frame); err != nil {
+			// Skip frames that aren't event payloads (e.g. pings).
+			continue
+		}
+
+		for _, e := range frame.Data {
+			if !cfg.matches(e) {
+				continue
+			}
+
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// sleepOrDone waits for d to elapse, returning false early if ctx is done.
+func sleepOrDone(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SubscribeEvents dials site's authenticated events WebSocket
+// (wss://host:8443/wss/s/$site/events) and streams decoded Event values on
+// the returned channel. Both channels are closed once ctx is cancelled or
+// the stream ends without WithReconnect. A non-nil value on the error
+// channel means the connection dropped; with WithReconnect, SubscribeEvents
+// keeps redialing after reporting it.
+//
+// Params:
+//     ctx: Parent context. You may use context.Background() to create an empty context.
+//          See http://godoc.org/context for more info.
+//     site: Site name. See AuthorizeGuestWithQos for details.
+//     opts: Options to customize the subscription. E.g. WithEventKeyPrefixes, WithReconnect.
+func (u *Unifi) SubscribeEvents(ctx context.Context, site string, opts ...SubscribeOption) (<-chan model.Event, <-chan error, error) {
+	var err error
+
+	defer logFnResult("SubscribeEvents", err)
+
+	if err = u.ensureControllerType(ctx); err != nil {
+		err = fmt.Errorf("ensureControllerType() error: %v", err)
+		return nil, nil, err
+	}
+
+	cfg := &subscribeConfig{maxBackoff: defaultMaxBackoff}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.maxBackoff <= 0 {
+		cfg.maxBackoff = defaultMaxBackoff
+	}
+
+	events := make(chan model.Event)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		defer close(errs)
+
+		backoff := time.Second
+
+		for {
+			conn, dialErr := u.dialEvents(ctx, site)
+			if dialErr != nil {
+				select {
+				case errs <- fmt.Errorf("dialEvents() error: %v", dialErr):
+				case <-ctx.Done():
+					return
+				}
+
+				if !cfg.reconnect || !sleepOrDone(ctx, backoff) {
+					return
+				}
+				if backoff *= 2; backoff > cfg.maxBackoff {
+					backoff = cfg.maxBackoff
+				}
+				continue
+			}
+
+			backoff = time.Second
+			readErr := u.readEvents(ctx, conn, cfg, events)
+			conn.Close()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			if readErr != nil {
+				select {
+				case errs <- readErr:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if !cfg.reconnect || !sleepOrDone(ctx, backoff) {
+				return
+			}
+			if backoff *= 2; backoff > cfg.maxBackoff {
+				backoff = cfg.maxBackoff
+			}
+		}
+	}()
+
+	return events, errs, err
+}
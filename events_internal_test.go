@@ -0,0 +1,55 @@
+package unifi
+
+import "testing"
+
+// TestEventsURL checks that eventsURL rewrites http/https to ws/wss and
+// injects the /proxy/network prefix for UniFi OS controllers.
+func TestEventsURL(t *testing.T) {
+	cases := []struct {
+		name           string
+		baseURL        string
+		controllerType ControllerType
+		site           string
+		want           string
+	}{
+		{
+			name:           "classic http",
+			baseURL:        "http://unifi.example.com:8080",
+			controllerType: ControllerClassic,
+			site:           "default",
+			want:           "ws://unifi.example.com:8080/wss/s/default/events",
+		},
+		{
+			name:           "unifi os https",
+			baseURL:        "https://udm.example.com",
+			controllerType: ControllerUniFiOS,
+			site:           "default",
+			want:           "wss://udm.example.com/proxy/network/wss/s/default/events",
+		},
+		{
+			name:           "empty site defaults",
+			baseURL:        "https://unifi.example.com:8443",
+			controllerType: ControllerClassic,
+			site:           "",
+			want:           "wss://unifi.example.com:8443/wss/s/default/events",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			u, err := New(c.baseURL, "admin", "pw", WithControllerType(c.controllerType))
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+
+			got, err := u.eventsURL(c.site)
+			if err != nil {
+				t.Fatalf("eventsURL() error: %v", err)
+			}
+
+			if got != c.want {
+				t.Errorf("eventsURL() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
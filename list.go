@@ -0,0 +1,121 @@
+package unifi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/northbright/unifi/model"
+)
+
+// EventsOptions customizes ListEvents.
+type EventsOptions struct {
+	// Limit caps the number of events returned. 0 means use the
+	// controller's default.
+	Limit int
+}
+
+// ListSta returns the client stations (wired and wireless) currently known
+// to site.
+//
+// Params:
+//     ctx: Parent context. You may use context.Background() to create an empty context.
+//          See http://godoc.org/context for more info.
+//     site: Site name. See AuthorizeGuestWithQos for details.
+func (u *Unifi) ListSta(ctx context.Context, site string) ([]model.STA, error) {
+	var err error
+
+	defer logFnResult("ListSta", err)
+
+	stas := []model.STA{}
+	if err = u.listData(ctx, "sta", site, &stas); err != nil {
+		err = fmt.Errorf("listData() error: %v", err)
+		return nil, err
+	}
+
+	return stas, err
+}
+
+// ListDevices returns the Unifi devices (APs, switches, gateways, ...)
+// adopted by site.
+//
+// Params:
+//     ctx: Parent context. You may use context.Background() to create an empty context.
+//          See http://godoc.org/context for more info.
+//     site: Site name. See AuthorizeGuestWithQos for details.
+func (u *Unifi) ListDevices(ctx context.Context, site string) ([]model.Device, error) {
+	var err error
+
+	defer logFnResult("ListDevices", err)
+
+	devices := []model.Device{}
+	if err = u.listData(ctx, "device", site, &devices); err != nil {
+		err = fmt.Errorf("listData() error: %v", err)
+		return nil, err
+	}
+
+	return devices, err
+}
+
+// ListSites returns every site managed by the controller.
+//
+// Params:
+//     ctx: Parent context. You may use context.Background() to create an empty context.
+//          See http://godoc.org/context for more info.
+func (u *Unifi) ListSites(ctx context.Context) ([]model.Site, error) {
+	var err error
+
+	defer logFnResult("ListSites", err)
+
+	sites := []model.Site{}
+	if err = u.listData(ctx, "sites", "", &sites); err != nil {
+		err = fmt.Errorf("listData() error: %v", err)
+		return nil, err
+	}
+
+	return sites, err
+}
+
+// ListHealth returns the per-subsystem health summary of site.
+//
+// Params:
+//     ctx: Parent context. You may use context.Background() to create an empty context.
+//          See http://godoc.org/context for more info.
+//     site: Site name. See AuthorizeGuestWithQos for details.
+func (u *Unifi) ListHealth(ctx context.Context, site string) ([]model.HealthStat, error) {
+	var err error
+
+	defer logFnResult("ListHealth", err)
+
+	health := []model.HealthStat{}
+	if err = u.listData(ctx, "health", site, &health); err != nil {
+		err = fmt.Errorf("listData() error: %v", err)
+		return nil, err
+	}
+
+	return health, err
+}
+
+// ListEvents returns the most recent events recorded for site.
+//
+// Params:
+//     ctx: Parent context. You may use context.Background() to create an empty context.
+//          See http://godoc.org/context for more info.
+//     site: Site name. See AuthorizeGuestWithQos for details.
+//     opts: Options to customize the returned events. E.g. Limit.
+func (u *Unifi) ListEvents(ctx context.Context, site string, opts EventsOptions) ([]model.Event, error) {
+	var err error
+
+	defer logFnResult("ListEvents", err)
+
+	events := []model.Event{}
+	if err = u.listData(ctx, "event", site, &events); err != nil {
+		err = fmt.Errorf("listData() error: %v", err)
+		return nil, err
+	}
+
+	if opts.Limit > 0 && len(events) > opts.Limit {
+		events = events[:opts.Limit]
+	}
+
+	return events, err
+}
@@ -0,0 +1,45 @@
+package unifi_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/northbright/unifi"
+)
+
+// TestLoginSendsCSRFToken checks that once detectControllerType has
+// captured a CSRF token from the root-URL probe, Login sends it back on its
+// own request instead of silently dropping it.
+func TestLoginSendsCSRFToken(t *testing.T) {
+	const csrfToken = "test-csrf-token"
+
+	loginSawToken := false
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(unifi.CSRFTokenHeader, csrfToken)
+	})
+	mux.HandleFunc("/api/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		loginSawToken = r.Header.Get(unifi.CSRFTokenHeader) == csrfToken
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[]}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u, err := unifi.New(srv.URL, "admin", "pw")
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	if err = u.Login(context.Background()); err != nil {
+		t.Fatalf("Login() error: %v", err)
+	}
+
+	if !loginSawToken {
+		t.Fatal("Login() did not send the CSRF token captured during controller-type detection")
+	}
+}
@@ -0,0 +1,86 @@
+// Package model defines typed representations of the JSON objects returned
+// by the Unifi Controller API, so callers don't have to reach into
+// map[string]interface{} themselves.
+package model
+
+// STA represents a client station (wired or wireless) known to the
+// controller, as returned by /stat/sta.
+type STA struct {
+	MAC        string `json:"mac"`
+	Hostname   string `json:"hostname"`
+	IP         string `json:"ip"`
+	Essid      string `json:"essid"`
+	IsWired    bool   `json:"is_wired"`
+	IsGuest    bool   `json:"is_guest"`
+	Authorized bool   `json:"authorized"`
+	Noted      bool   `json:"noted"`
+	FirstSeen  int64  `json:"first_seen"`
+	LastSeen   int64  `json:"last_seen"`
+	RxBytes    int64  `json:"rx_bytes"`
+	TxBytes    int64  `json:"tx_bytes"`
+}
+
+// Device represents a Unifi device (AP, switch, gateway, ...) adopted by the
+// site, as returned by /stat/device.
+type Device struct {
+	MAC     string `json:"mac"`
+	Name    string `json:"name"`
+	Model   string `json:"model"`
+	Type    string `json:"type"`
+	IP      string `json:"ip"`
+	Adopted bool   `json:"adopted"`
+	State   int    `json:"state"`
+	Version string `json:"version"`
+}
+
+// Site represents a site managed by the controller, as returned by
+// /self/sites.
+type Site struct {
+	ID   string `json:"_id"`
+	Name string `json:"name"`
+	Desc string `json:"desc"`
+	Role string `json:"role"`
+}
+
+// HealthStat represents one subsystem's health summary for a site, as
+// returned by /stat/health.
+type HealthStat struct {
+	Subsystem   string `json:"subsystem"`
+	Status      string `json:"status"`
+	NumUser     int    `json:"num_user"`
+	NumGuest    int    `json:"num_guest"`
+	NumAP       int    `json:"num_ap"`
+	NumAdopted  int    `json:"num_adopted"`
+	NumDisabled int    `json:"num_disabled"`
+	NumPending  int    `json:"num_pending"`
+}
+
+// Voucher represents a hotspot guest access voucher, as returned by
+// /stat/voucher and by the cmd/hotspot create-voucher command.
+type Voucher struct {
+	ID             string `json:"_id"`
+	Code           string `json:"code"`
+	Note           string `json:"note"`
+	Duration       int    `json:"duration"`
+	QOSUsageQuota  int    `json:"qos_usage_quota,omitempty"`
+	QOSRateMaxUp   int    `json:"qos_rate_max_up,omitempty"`
+	QOSRateMaxDown int    `json:"qos_rate_max_down,omitempty"`
+	Quota          int    `json:"quota"`
+	Used           int    `json:"used"`
+	CreateTime     int64  `json:"create_time"`
+}
+
+// Event represents a client, guest, or system event, as returned by
+// /stat/event and streamed over the events WebSocket.
+type Event struct {
+	ID        string `json:"_id"`
+	Key       string `json:"key"`
+	Msg       string `json:"msg"`
+	Subsystem string `json:"subsystem"`
+	Time      int64  `json:"time"`
+	Datetime  string `json:"datetime"`
+	SiteID    string `json:"site_id"`
+	User      string `json:"user"`
+	Guest     string `json:"guest"`
+	SSID      string `json:"ssid"`
+}
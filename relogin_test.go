@@ -0,0 +1,65 @@
+package unifi_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/northbright/unifi"
+)
+
+// TestReLoginOnSessionExpiry checks that a 401 carrying the controller's
+// LoginRequired body triggers exactly one transparent re-login, after which
+// the original request is retried and succeeds.
+func TestReLoginOnSessionExpiry(t *testing.T) {
+	const site = "default"
+
+	loginCount := 0
+	staRequestCount := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
+		loginCount++
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[]}`)
+	})
+	mux.HandleFunc("/api/s/"+site+"/stat/sta", func(w http.ResponseWriter, r *http.Request) {
+		staRequestCount++
+		if staRequestCount == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"meta":{"rc":"error","msg":"api.err.LoginRequired"},"data":[]}`)
+			return
+		}
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[{"mac":"aa:bb:cc:dd:ee:ff"}]}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u, err := unifi.New(srv.URL, "admin", "pw", unifi.WithControllerType(unifi.ControllerClassic))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	if err = u.Login(context.Background()); err != nil {
+		t.Fatalf("Login() error: %v", err)
+	}
+
+	stas, err := u.ListSta(context.Background(), site)
+	if err != nil {
+		t.Fatalf("ListSta() error: %v", err)
+	}
+
+	if len(stas) != 1 {
+		t.Fatalf("ListSta() = %+v, want 1 STA", stas)
+	}
+
+	if staRequestCount != 2 {
+		t.Fatalf("stat/sta was requested %v times, want 2 (initial + retry)", staRequestCount)
+	}
+
+	if loginCount != 2 {
+		t.Fatalf("login was requested %v times, want 2 (initial + re-login)", loginCount)
+	}
+}
@@ -6,35 +6,186 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
 )
 
 const (
 	defaultSite = "default"
+
+	// CSRFTokenHeader is the header UniFi OS echoes on every response once
+	// authenticated, and which must be sent back on every mutating request.
+	CSRFTokenHeader = "X-CSRF-Token"
+
+	// EnvUserName and EnvPassword, when set, take precedence over both the
+	// userName/password arguments passed to New and any credentials
+	// embedded in its unifiURL.
+	EnvUserName = "UNIFI_USERNAME"
+	EnvPassword = "UNIFI_PASSWORD"
+
+	// errLoginRequired is the "rc" error code the controller returns in the
+	// response body once the session cookie has expired.
+	errLoginRequired = "api.err.LoginRequired"
+)
+
+// ControllerType identifies the flavor of Unifi Controller being talked to.
+// The legacy software controller and UniFi OS (UDM/UDM-Pro, and standalone
+// v6.x+) expose the same APIs under different URL prefixes and only UniFi OS
+// requires CSRF tokens.
+type ControllerType int
+
+const (
+	// ControllerUnknown means the flavor hasn't been detected yet. New()
+	// leaves it in this state unless WithControllerType is used, and it is
+	// resolved the first time a request is made.
+	ControllerUnknown ControllerType = iota
+	// ControllerClassic is the legacy standalone software controller
+	// (pre-UniFi OS), serving APIs directly under /api.
+	ControllerClassic
+	// ControllerUniFiOS is a UDM/UDM-Pro or any v6.x+ controller, which
+	// proxies the network application's APIs under /proxy/network and
+	// requires the X-CSRF-Token header on mutating requests.
+	ControllerUniFiOS
 )
 
 var (
 	debugMode = false
-	rawURLs   = map[string]string{
+
+	// rawURLsClassic are the API paths on a legacy standalone controller.
+	rawURLsClassic = map[string]string{
 		"login":  "/api/login",
 		"logout": "/api/logout",
 		"stamgr": "/api/s/$site/cmd/stamgr",
+		"sta":    "/api/s/$site/stat/sta",
+		"device": "/api/s/$site/stat/device",
+		"sites":  "/api/self/sites",
+		"health": "/api/s/$site/stat/health",
+		"event":  "/api/s/$site/stat/event",
+	}
+
+	// rawURLsUniFiOS are the same API paths as served through a UniFi OS
+	// controller's reverse proxy in front of the network application.
+	rawURLsUniFiOS = map[string]string{
+		"login":  "/api/auth/login",
+		"logout": "/api/auth/logout",
+		"stamgr": "/proxy/network/api/s/$site/cmd/stamgr",
+		"sta":    "/proxy/network/api/s/$site/stat/sta",
+		"device": "/proxy/network/api/s/$site/stat/device",
+		"sites":  "/proxy/network/api/self/sites",
+		"health": "/proxy/network/api/s/$site/stat/health",
+		"event":  "/proxy/network/api/s/$site/stat/event",
 	}
 )
 
 // Unifi provides functions to call Unifi APIs.
 type Unifi struct {
-	userName string
-	password string
-	baseURL  *url.URL
-	urls     map[string]*url.URL
-	jar      *cookiejar.Jar
+	userName  string
+	password  string
+	baseURL   *url.URL
+	jar       *cookiejar.Jar
+	client    *http.Client
+	transport http.RoundTripper
+	tlsConfig *tls.Config
+	timeout   time.Duration
+
+	// mu guards controllerType, urls and csrfToken, which detectControllerType
+	// and setCSRFToken may mutate from any goroutine calling a Unifi method
+	// concurrently, e.g. two goroutines both triggering auto-detection on
+	// their first call after New.
+	mu             sync.RWMutex
+	controllerType ControllerType
+	urls           map[string]*url.URL
+	csrfToken      string
+}
+
+// Option configures a Unifi created by New.
+type Option func(*Unifi)
+
+// WithControllerType forces the controller flavor instead of letting New
+// auto-detect it by probing the controller's root URL on first use.
+func WithControllerType(t ControllerType) Option {
+	return func(u *Unifi) {
+		u.controllerType = t
+	}
+}
+
+// WithHTTPClient makes Unifi use c instead of building its own client. If
+// c.Jar is nil, Unifi's cookie jar is attached to it so the session cookie
+// set by Login is still sent on subsequent requests. Use this to share a
+// client across multiple Unifi instances, to instrument it, or to pass a
+// client configured for mTLS.
+func WithHTTPClient(c *http.Client) Option {
+	return func(u *Unifi) {
+		u.client = c
+	}
+}
+
+// WithTransport sets the RoundTripper used by the client New builds. It has
+// no effect if WithHTTPClient is also used. Use this to point at a proxy, to
+// instrument requests, or to customize dial behavior.
+func WithTransport(t http.RoundTripper) Option {
+	return func(u *Unifi) {
+		u.transport = t
+	}
+}
+
+// WithTLSConfig sets the TLS config used by the default transport New
+// builds. It has no effect if WithHTTPClient or WithTransport is also used.
+// TLS verification is performed by default; pass a config with
+// InsecureSkipVerify set to disable it.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(u *Unifi) {
+		u.tlsConfig = cfg
+	}
+}
+
+// WithTimeout sets the timeout of the client New builds. It has no effect
+// if WithHTTPClient is also used.
+func WithTimeout(d time.Duration) Option {
+	return func(u *Unifi) {
+		u.timeout = d
+	}
+}
+
+// rawURLsForControllerType returns the API path templates for t, defaulting
+// to the legacy controller's paths when t is ControllerUnknown.
+func rawURLsForControllerType(t ControllerType) map[string]string {
+	if t == ControllerUniFiOS {
+		return rawURLsUniFiOS
+	}
+	return rawURLsClassic
+}
+
+// buildURLs (re)resolves u.urls against u.baseURL for u.controllerType.
+// Callers must hold u.mu for writing.
+func (u *Unifi) buildURLs() {
+	urls := map[string]*url.URL{}
+	for k, v := range rawURLsForControllerType(u.controllerType) {
+		refURL, _ := url.Parse(v)
+		urls[k] = u.baseURL.ResolveReference(refURL)
+	}
+	u.urls = urls
+}
+
+// urlString returns the String() of the URL registered under urlKey,
+// guarding against a concurrent detectControllerType swapping u.urls out
+// from under the read.
+func (u *Unifi) urlString(urlKey string) string {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	return u.urls[urlKey].String()
 }
 
 // SetDebugMode sets debug mode for package unifi.
@@ -71,35 +222,139 @@ func logFnResult(funcName string, err error) {
 //     unifiURL: Unifi Controller's URL. E.g. https://10.0.1.100:8443
 //     userName: User name of Unifi Controller.
 //     password: Password of Unifi Controller.
-func New(unifiURL, userName, password string) (*Unifi, error) {
+//     opts: Options to customize the Unifi. E.g. WithControllerType.
+func New(unifiURL, userName, password string, opts ...Option) (*Unifi, error) {
 	var err error
 
 	defer logFnResult("New", err)
 
-	u := &Unifi{}
+	u := &Unifi{controllerType: ControllerUnknown}
 
 	if u.baseURL, err = url.Parse(unifiURL); err != nil {
 		err = fmt.Errorf("Parse Unifi URL error: %v", err)
 		return u, err
 	}
 
-	u.urls = map[string]*url.URL{}
-	for k, v := range rawURLs {
-		refURL, _ := url.Parse(v)
-		u.urls[k] = u.baseURL.ResolveReference(refURL)
+	// Credentials embedded in the URL(e.g. https://admin:pw@host:8443) are
+	// used as a fallback when userName / password are empty, and stripped
+	// from baseURL afterwards so the http.Client does not also send them as
+	// HTTP Basic Auth.
+	if u.baseURL.User != nil {
+		if userName == "" {
+			userName = u.baseURL.User.Username()
+		}
+		if password == "" {
+			if p, ok := u.baseURL.User.Password(); ok {
+				password = p
+			}
+		}
+		u.baseURL.User = nil
+	}
+
+	// Env vars take precedence over both of the above.
+	if v := os.Getenv(EnvUserName); v != "" {
+		userName = v
+	}
+	if v := os.Getenv(EnvPassword); v != "" {
+		password = v
 	}
 
 	u.userName = userName
 	u.password = password
 
-	if u.jar, err = cookiejar.New(nil); err != nil {
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	u.buildURLs()
+
+	if u.jar, err = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List}); err != nil {
 		err = fmt.Errorf("cookiejar.New() error: %v", err)
 		return u, err
 	}
 
+	if u.client == nil {
+		transport := u.transport
+		if transport == nil {
+			transport = &http.Transport{TLSClientConfig: u.tlsConfig}
+		}
+		u.client = &http.Client{Transport: transport, Timeout: u.timeout}
+	}
+	if u.client.Jar == nil {
+		u.client.Jar = u.jar
+	}
+
 	return u, err
 }
 
+// detectControllerType probes the controller's root URL and inspects the
+// response for the X-CSRF-Token header, which only UniFi OS sends. It
+// rebuilds u.urls if the detected flavor differs from the current one.
+func (u *Unifi) detectControllerType(ctx context.Context) error {
+	var err error
+
+	defer logFnResult("detectControllerType", err)
+
+	req, err := http.NewRequest("GET", u.baseURL.String(), nil)
+	if err != nil {
+		err = fmt.Errorf("NewRequest error: %v", err)
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("client.Do() error: %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	controllerType := ControllerClassic
+	csrfToken := resp.Header.Get(CSRFTokenHeader)
+	if csrfToken != "" {
+		controllerType = ControllerUniFiOS
+	}
+
+	u.mu.Lock()
+	if csrfToken != "" {
+		u.csrfToken = csrfToken
+	}
+	if controllerType != u.controllerType {
+		u.controllerType = controllerType
+		u.buildURLs()
+	}
+	u.mu.Unlock()
+
+	return err
+}
+
+// ensureControllerType resolves u.controllerType if it hasn't been detected
+// or explicitly set yet. Safe to call from multiple goroutines concurrently,
+// even before the first successful detection.
+func (u *Unifi) ensureControllerType(ctx context.Context) error {
+	u.mu.RLock()
+	known := u.controllerType != ControllerUnknown
+	u.mu.RUnlock()
+
+	if known {
+		return nil
+	}
+	return u.detectControllerType(ctx)
+}
+
+// setCSRFToken records the CSRF token echoed by UniFi OS on resp, if any, so
+// it can be sent back on the next mutating request.
+func (u *Unifi) setCSRFToken(resp *http.Response) {
+	t := resp.Header.Get(CSRFTokenHeader)
+	if t == "" {
+		return
+	}
+
+	u.mu.Lock()
+	u.csrfToken = t
+	u.mu.Unlock()
+}
+
 // ParseJSON parses the JSON returned by Unifi APIs.
 //
 // Params:
@@ -138,6 +393,201 @@ func ParseJSON(b []byte) (map[string]interface{}, bool, error) {
 	return m, rc == "ok", err
 }
 
+// doOnce sends a single HTTP request built from method, urlStr and body
+// (nil for no body), and returns the response along with its fully-read
+// body.
+func (u *Unifi) doOnce(ctx context.Context, method, urlStr, contentType string, body []byte) (*http.Response, []byte, error) {
+	var err error
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewBuffer(body)
+	}
+
+	req, err := http.NewRequest(method, urlStr, reqBody)
+	if err != nil {
+		err = fmt.Errorf("NewRequest error: %v", err)
+		return nil, nil, err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Accept", "*/*")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	u.mu.RLock()
+	csrfToken := u.csrfToken
+	u.mu.RUnlock()
+	if csrfToken != "" {
+		req.Header.Set(CSRFTokenHeader, csrfToken)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("client.Do() error: %v", err)
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = fmt.Errorf("ReadAll() error: %v", err)
+		return resp, nil, err
+	}
+
+	u.setCSRFToken(resp)
+
+	return resp, respBody, err
+}
+
+// sessionExpired reports whether resp/body is the controller's way of
+// saying the session cookie has expired and a fresh Login is required.
+func sessionExpired(resp *http.Response, body []byte) bool {
+	if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+		return false
+	}
+	return bytes.Contains(body, []byte(errLoginRequired))
+}
+
+// do is like doOnce, except that if the controller reports the session has
+// expired it transparently calls Login once and retries the request, so
+// long-running callers don't have to detect and handle re-login themselves.
+func (u *Unifi) do(ctx context.Context, method, urlStr, contentType string, body []byte) (*http.Response, []byte, error) {
+	resp, respBody, err := u.doOnce(ctx, method, urlStr, contentType, body)
+	if err != nil {
+		return resp, respBody, err
+	}
+
+	if !sessionExpired(resp, respBody) {
+		return resp, respBody, err
+	}
+
+	if err = u.Login(ctx); err != nil {
+		err = fmt.Errorf("re-login after session expiry error: %v", err)
+		return resp, respBody, err
+	}
+
+	return u.doOnce(ctx, method, urlStr, contentType, body)
+}
+
+// get performs an authenticated GET against the API path registered under
+// urlKey, with any "$site" placeholder replaced by site, and returns the
+// raw response body.
+func (u *Unifi) get(ctx context.Context, urlKey, site string) ([]byte, error) {
+	var err error
+
+	defer logFnResult("get", err)
+
+	if err = u.ensureControllerType(ctx); err != nil {
+		err = fmt.Errorf("ensureControllerType() error: %v", err)
+		return nil, err
+	}
+
+	if site == "" {
+		site = defaultSite
+	}
+
+	urlStr := strings.Replace(u.urlString(urlKey), "$site", site, -1)
+
+	resp, b, err := u.do(ctx, "GET", urlStr, "", nil)
+	if err != nil {
+		err = fmt.Errorf("do() error: %v", err)
+		return nil, err
+	}
+
+	if debugMode {
+		log.Printf("get(%v): response: %v", urlKey, string(b))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("response status code: %v", resp.StatusCode)
+		return nil, err
+	}
+
+	return b, err
+}
+
+// post performs an authenticated POST of args, JSON-encoded, against the API
+// path registered under urlKey, with any "$site" placeholder replaced by
+// site, and returns the raw response body.
+func (u *Unifi) post(ctx context.Context, urlKey, site string, args interface{}) ([]byte, error) {
+	var err error
+
+	defer logFnResult("post", err)
+
+	if err = u.ensureControllerType(ctx); err != nil {
+		err = fmt.Errorf("ensureControllerType() error: %v", err)
+		return nil, err
+	}
+
+	if site == "" {
+		site = defaultSite
+	}
+
+	b, err := json.Marshal(args)
+	if err != nil {
+		err = fmt.Errorf("json.Marshal() error: %v", err)
+		return nil, err
+	}
+
+	urlStr := strings.Replace(u.urlString(urlKey), "$site", site, -1)
+
+	if debugMode {
+		log.Printf("post(%v): POST URL: %v", urlKey, urlStr)
+		log.Printf("post(%v): POST data: %v", urlKey, string(b))
+	}
+
+	resp, respBody, err := u.do(ctx, "POST", urlStr, "application/json", b)
+	if err != nil {
+		err = fmt.Errorf("do() error: %v", err)
+		return nil, err
+	}
+
+	if debugMode {
+		log.Printf("post(%v): response: %v", urlKey, string(respBody))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("response status code: %v", resp.StatusCode)
+		return nil, err
+	}
+
+	return respBody, err
+}
+
+// listData GETs urlKey, checks that "rc" is "ok" and unmarshals the "data"
+// array of the response into v.
+func (u *Unifi) listData(ctx context.Context, urlKey, site string, v interface{}) error {
+	var err error
+
+	b, err := u.get(ctx, urlKey, site)
+	if err != nil {
+		return err
+	}
+
+	m, ok, err := ParseJSON(b)
+	if err != nil {
+		err = fmt.Errorf("ParseJSON() error: %v", err)
+		return err
+	}
+	if !ok {
+		err = fmt.Errorf("rc is not ok")
+		return err
+	}
+
+	data, err := json.Marshal(m["data"])
+	if err != nil {
+		err = fmt.Errorf("json.Marshal() error: %v", err)
+		return err
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		err = fmt.Errorf("json.Unmarshal() error: %v", err)
+		return err
+	}
+
+	return err
+}
+
 // Login logins Unifi Controller.
 //
 // Params:
@@ -148,6 +598,11 @@ func (u *Unifi) Login(ctx context.Context) error {
 
 	defer logFnResult("Login", err)
 
+	if err = u.ensureControllerType(ctx); err != nil {
+		err = fmt.Errorf("ensureControllerType() error: %v", err)
+		return err
+	}
+
 	// POST data is in JSON format.
 	args := struct {
 		Username string `json:"username"`
@@ -163,40 +618,15 @@ func (u *Unifi) Login(ctx context.Context) error {
 		return err
 	}
 
-	buf := bytes.NewBuffer(b)
-
 	// Login.
-	req, err := http.NewRequest("POST", u.urls["login"].String(), buf)
-	if err != nil {
-		err = fmt.Errorf("NewRequest error: %v", err)
-		return err
-	}
-	// Get a copy of req with its context changed to ctx.
-	req = req.WithContext(ctx)
-
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Content-Type", "application/json")
-
-	tr := &http.Transport{
-		// Skip cert verify.
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr}
-
-	resp, err := client.Do(req)
+	resp, respBody, err := u.doOnce(ctx, "POST", u.urlString("login"), "application/json", b)
 	if err != nil {
-		err = fmt.Errorf("client.Do() error: %v", err)
+		err = fmt.Errorf("doOnce() error: %v", err)
 		return err
 	}
-	defer resp.Body.Close()
 
 	if debugMode {
-		b, err = ioutil.ReadAll(resp.Body)
-		if err != nil {
-			err = fmt.Errorf("ReadAll() error: %v", err)
-			return err
-		}
-		log.Printf("Login() response: %v", string(b))
+		log.Printf("Login() response: %v", string(respBody))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -204,10 +634,6 @@ func (u *Unifi) Login(ctx context.Context) error {
 		return err
 	}
 
-	respCookies := resp.Cookies()
-	// Set cookie for cookiejar manually.
-	u.jar.SetCookies(u.baseURL, respCookies)
-
 	return err
 }
 
@@ -221,37 +647,20 @@ func (u *Unifi) Logout(ctx context.Context) error {
 
 	defer logFnResult("Logout", err)
 
-	// Logout.
-	// Method: POST.
-	req, err := http.NewRequest("POST", u.urls["logout"].String(), nil)
-	if err != nil {
-		err = fmt.Errorf("NewRequest error: %v", err)
+	if err = u.ensureControllerType(ctx); err != nil {
+		err = fmt.Errorf("ensureControllerType() error: %v", err)
 		return err
 	}
-	// Get a copy of req with its context changed to ctx.
-	req = req.WithContext(ctx)
-
-	req.Header.Set("Accept", "*/*")
-
-	tr := &http.Transport{
-		// Skip cert verify.
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr, Jar: u.jar}
 
-	resp, err := client.Do(req)
+	// Logout.
+	// Method: POST.
+	resp, b, err := u.doOnce(ctx, "POST", u.urlString("logout"), "", nil)
 	if err != nil {
-		err = fmt.Errorf("client.Do() error: %v", err)
+		err = fmt.Errorf("doOnce() error: %v", err)
 		return err
 	}
-	defer resp.Body.Close()
 
 	if debugMode {
-		b, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			err = fmt.Errorf("ReadAll() error: %v", err)
-			return err
-		}
 		log.Printf("Logout() response: %v", string(b))
 	}
 
@@ -260,10 +669,6 @@ func (u *Unifi) Logout(ctx context.Context) error {
 		return err
 	}
 
-	respCookies := resp.Cookies()
-	// Set cookie for cookiejar manually.
-	u.jar.SetCookies(u.baseURL, respCookies)
-
 	return err
 }
 
@@ -307,66 +712,11 @@ func (u *Unifi) AuthorizeGuestWithQos(ctx context.Context, site, mac string, min
 		args["bytes"] = strconv.Itoa(quota)
 	}
 
-	b, err := json.Marshal(args)
-	if err != nil {
-		err = fmt.Errorf("json.Marshal() error: %v", err)
-		return err
-	}
-
-	buf := bytes.NewBuffer(b)
-
-	urlStr := u.urls["stamgr"].String()
-	// Replace $site with real site.
-	urlStr = strings.Replace(urlStr, "$site", site, -1)
-
-	if debugMode {
-		log.Printf("AuthorizeGuestWithQos(): POST URL: %v", urlStr)
-		log.Printf("AuthorizeGuestWithQos(): POST data: %v", string(b))
-	}
-
-	// Authorize Guest.
-	req, err := http.NewRequest("POST", urlStr, buf)
-	if err != nil {
-		err = fmt.Errorf("NewRequest error: %v", err)
-		return err
-	}
-	// Get a copy of req with its context changed to ctx.
-	req = req.WithContext(ctx)
-
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Content-Type", "application/json")
-
-	tr := &http.Transport{
-		// Skip cert verify.
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr, Jar: u.jar}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		err = fmt.Errorf("client.Do() error: %v", err)
-		return err
-	}
-	defer resp.Body.Close()
-
-	if debugMode {
-		b, err = ioutil.ReadAll(resp.Body)
-		if err != nil {
-			err = fmt.Errorf("ReadAll() error: %v", err)
-			return err
-		}
-		log.Printf("AuthorizeGuest() response: %v", string(b))
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("response status code: %v", resp.StatusCode)
+	if _, err = u.post(ctx, "stamgr", site, args); err != nil {
+		err = fmt.Errorf("post() error: %v", err)
 		return err
 	}
 
-	respCookies := resp.Cookies()
-	// Set cookie for cookiejar manually.
-	u.jar.SetCookies(u.baseURL, respCookies)
-
 	return err
 }
 
@@ -0,0 +1,168 @@
+package unifi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/northbright/unifi/model"
+)
+
+func init() {
+	rawURLsClassic["hotspot"] = "/api/s/$site/cmd/hotspot"
+	rawURLsClassic["voucher"] = "/api/s/$site/stat/voucher"
+	rawURLsUniFiOS["hotspot"] = "/proxy/network/api/s/$site/cmd/hotspot"
+	rawURLsUniFiOS["voucher"] = "/proxy/network/api/s/$site/stat/voucher"
+}
+
+// VoucherOptions customizes CreateVouchers.
+//
+// Fields:
+//     Quota: Number of times a voucher may be used. 0 means single-use,
+//            matching the controller's own default.
+//     Down: Max download speed in KB. Same unit as AuthorizeGuestWithQos.
+//     Up: Max upload speed in KB. Same unit as AuthorizeGuestWithQos.
+//     Bytes: Data transfer quota in MB. Same unit as AuthorizeGuestWithQos.
+//     Note: Optional note attached to the vouchers.
+type VoucherOptions struct {
+	Quota int
+	Down  int
+	Up    int
+	Bytes int
+	Note  string
+}
+
+// CreateVouchers creates count hotspot guest access vouchers, each expiring
+// expireMin minutes after first use.
+//
+// Params:
+//     ctx: Parent context. You may use context.Background() to create an empty context.
+//          See http://godoc.org/context for more info.
+//     site: Site name. See AuthorizeGuestWithQos for details.
+//     count: Number of vouchers to create.
+//     expireMin: Expiry in minutes.
+//     opts: Options to customize the vouchers. E.g. Quota, Down, Up, Bytes, Note.
+func (u *Unifi) CreateVouchers(ctx context.Context, site string, count, expireMin int, opts VoucherOptions) ([]model.Voucher, error) {
+	var err error
+
+	defer logFnResult("CreateVouchers", err)
+
+	args := map[string]interface{}{
+		"cmd":    "create-voucher",
+		"n":      count,
+		"expire": expireMin,
+		"quota":  opts.Quota,
+		"note":   opts.Note,
+	}
+
+	if opts.Down > 0 {
+		args["down"] = opts.Down
+	}
+
+	if opts.Up > 0 {
+		args["up"] = opts.Up
+	}
+
+	if opts.Bytes > 0 {
+		args["bytes"] = opts.Bytes
+	}
+
+	b, err := u.post(ctx, "hotspot", site, args)
+	if err != nil {
+		err = fmt.Errorf("post() error: %v", err)
+		return nil, err
+	}
+
+	m, ok, err := ParseJSON(b)
+	if err != nil {
+		err = fmt.Errorf("ParseJSON() error: %v", err)
+		return nil, err
+	}
+	if !ok {
+		err = fmt.Errorf("rc is not ok")
+		return nil, err
+	}
+
+	data, err := json.Marshal(m["data"])
+	if err != nil {
+		err = fmt.Errorf("json.Marshal() error: %v", err)
+		return nil, err
+	}
+
+	created := []struct {
+		CreateTime int64 `json:"create_time"`
+	}{}
+	if err = json.Unmarshal(data, &created); err != nil {
+		err = fmt.Errorf("json.Unmarshal() error: %v", err)
+		return nil, err
+	}
+	if len(created) == 0 {
+		err = fmt.Errorf("create-voucher response has no data")
+		return nil, err
+	}
+	createTime := created[0].CreateTime
+
+	// create-voucher only returns a create_time, not the voucher codes
+	// themselves. List them back out and keep only the ones just created,
+	// so sites with pre-existing vouchers don't leak into the result.
+	all, err := u.ListVouchers(ctx, site)
+	if err != nil {
+		err = fmt.Errorf("ListVouchers() error: %v", err)
+		return nil, err
+	}
+
+	vouchers := []model.Voucher{}
+	for _, v := range all {
+		if v.CreateTime == createTime {
+			vouchers = append(vouchers, v)
+		}
+	}
+
+	return vouchers, err
+}
+
+// ListVouchers returns all hotspot guest access vouchers created for site.
+//
+// Params:
+//     ctx: Parent context. You may use context.Background() to create an empty context.
+//          See http://godoc.org/context for more info.
+//     site: Site name. See AuthorizeGuestWithQos for details.
+func (u *Unifi) ListVouchers(ctx context.Context, site string) ([]model.Voucher, error) {
+	var err error
+
+	defer logFnResult("ListVouchers", err)
+
+	vouchers := []model.Voucher{}
+	if err = u.listData(ctx, "voucher", site, &vouchers); err != nil {
+		err = fmt.Errorf("listData() error: %v", err)
+		return nil, err
+	}
+
+	return vouchers, err
+}
+
+// RevokeVoucher deletes the hotspot guest access voucher identified by id
+// (its "_id" field, as returned by ListVouchers or CreateVouchers).
+//
+// Params:
+//     ctx: Parent context. You may use context.Background() to create an empty context.
+//          See http://godoc.org/context for more info.
+//     site: Site name. See AuthorizeGuestWithQos for details.
+//     id: Voucher's "_id".
+func (u *Unifi) RevokeVoucher(ctx context.Context, site, id string) error {
+	var err error
+
+	defer logFnResult("RevokeVoucher", err)
+
+	args := map[string]string{
+		"cmd": "delete-voucher",
+		"_id": id,
+	}
+
+	if _, err = u.post(ctx, "hotspot", site, args); err != nil {
+		err = fmt.Errorf("post() error: %v", err)
+		return err
+	}
+
+	return err
+}
@@ -0,0 +1,53 @@
+package unifi_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/northbright/unifi"
+)
+
+// TestCreateVouchers checks that CreateVouchers only returns the vouchers it
+// just created, even when the site already has other vouchers.
+func TestCreateVouchers(t *testing.T) {
+	const site = "default"
+	const createTime = 1234567890
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/s/"+site+"/cmd/hotspot", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"meta":{"rc":"ok"},"data":[{"create_time":%d}]}`, createTime)
+	})
+	mux.HandleFunc("/api/s/"+site+"/stat/voucher", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"meta":{"rc":"ok"},"data":[
+			{"_id":"old1","code":"OLD1","create_time":1},
+			{"_id":"new1","code":"NEW1","create_time":%d},
+			{"_id":"new2","code":"NEW2","create_time":%d}
+		]}`, createTime, createTime)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u, err := unifi.New(srv.URL, "admin", "pw", unifi.WithControllerType(unifi.ControllerClassic))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	vouchers, err := u.CreateVouchers(context.Background(), site, 2, 60, unifi.VoucherOptions{})
+	if err != nil {
+		t.Fatalf("CreateVouchers() error: %v", err)
+	}
+
+	if len(vouchers) != 2 {
+		t.Fatalf("len(vouchers) = %v, want 2", len(vouchers))
+	}
+
+	for _, v := range vouchers {
+		if v.Code == "OLD1" {
+			t.Fatalf("CreateVouchers() returned pre-existing voucher %v", v.Code)
+		}
+	}
+}